@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const (
+	backoffInitial = time.Second
+	backoffCap     = 2 * time.Minute
+)
+
+// isTransientSTSError reports whether err is a throttling or server-side
+// STS error worth retrying, as opposed to a permanent failure like bad
+// credentials or an invalid MFA code.
+func isTransientSTSError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequestsException":
+		return true
+	}
+
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() >= 500
+	}
+
+	return false
+}
+
+// withBackoff retries fn with exponential backoff capped at backoffCap
+// on transient STS errors, and returns immediately on success or a
+// permanent error. It never gives up on a transient error: in -renew
+// mode a sustained throttle should keep being retried rather than
+// killing the process.
+func withBackoff(fn func() error) error {
+	backoff := backoffInitial
+
+	for {
+		err := fn()
+		if err == nil || !isTransientSTSError(err) {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Transient STS error, retrying in %s: %v\n", backoff, err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > backoffCap {
+			backoff = backoffCap
+		}
+	}
+}
+
+// refreshDelay returns how long to sleep before the next proactive
+// credential refresh: refreshBefore ahead of expiration, minus a random
+// jitter so a fleet of swamp processes doesn't re-auth in lockstep.
+func refreshDelay(expiration *time.Time, refreshBefore, jitter time.Duration) time.Duration {
+	if expiration == nil {
+		return 0
+	}
+
+	j := time.Duration(0)
+	if jitter > 0 {
+		j = time.Duration(rand.Int63n(int64(jitter)))
+	}
+
+	delay := time.Until(*expiration) - refreshBefore - j
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay
+}
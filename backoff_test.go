@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestIsTransientSTSError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"plain error", errors.New("boom"), false},
+		{"throttling code", awserr.New("Throttling", "slow down", nil), true},
+		{"throttling exception code", awserr.New("ThrottlingException", "slow down", nil), true},
+		{"request limit exceeded", awserr.New("RequestLimitExceeded", "too fast", nil), true},
+		{"too many requests", awserr.New("TooManyRequestsException", "too fast", nil), true},
+		{"permanent aws error", awserr.New("AccessDenied", "nope", nil), false},
+		{"5xx request failure", awserr.NewRequestFailure(awserr.New("InternalFailure", "oops", nil), 500, "req-1"), true},
+		{"4xx request failure", awserr.NewRequestFailure(awserr.New("ValidationError", "bad input", nil), 400, "req-2"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientSTSError(tt.err); got != tt.want {
+				t.Errorf("isTransientSTSError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshDelay(t *testing.T) {
+	t.Run("nil expiration returns 0", func(t *testing.T) {
+		if got := refreshDelay(nil, 5*time.Minute, 0); got != 0 {
+			t.Errorf("refreshDelay(nil, ...) = %v, want 0", got)
+		}
+	})
+
+	t.Run("refresh-before larger than remaining lifetime clamps to 0", func(t *testing.T) {
+		expiration := time.Now().Add(1 * time.Minute)
+		if got := refreshDelay(&expiration, 5*time.Minute, 0); got != 0 {
+			t.Errorf("refreshDelay = %v, want 0", got)
+		}
+	})
+
+	t.Run("jitter larger than remaining lifetime clamps to 0", func(t *testing.T) {
+		expiration := time.Now().Add(1 * time.Minute)
+		if got := refreshDelay(&expiration, 0, 5*time.Minute); got != 0 {
+			t.Errorf("refreshDelay = %v, want 0", got)
+		}
+	})
+
+	t.Run("sleeps until refresh-before window without jitter", func(t *testing.T) {
+		expiration := time.Now().Add(1 * time.Hour)
+		got := refreshDelay(&expiration, 5*time.Minute, 0)
+		want := 55 * time.Minute
+		if diff := got - want; diff < -time.Second || diff > time.Second {
+			t.Errorf("refreshDelay = %v, want ~%v", got, want)
+		}
+	})
+
+	t.Run("jitter never pushes the delay past the refresh-before window", func(t *testing.T) {
+		expiration := time.Now().Add(1 * time.Hour)
+		jitter := 30 * time.Second
+		refreshBefore := 5 * time.Minute
+		for i := 0; i < 50; i++ {
+			got := refreshDelay(&expiration, refreshBefore, jitter)
+			max := 55*time.Minute + time.Second
+			if got > max {
+				t.Fatalf("refreshDelay = %v, want <= %v", got, max)
+			}
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// SwampConfig holds the flags that drive a single swamp run: the base
+// profile/MFA device used to obtain an intermediate session, and the
+// role to assume into from that session.
+type SwampConfig struct {
+	profile              string
+	intermediateProfile  string
+	targetProfile        string
+	region               string
+	tokenSerialNumber    string
+	intermediateDuration int64
+	targetDuration       int64
+	renew                bool
+	exportProfile        bool
+	exportFile           string
+	useInstanceProfile   bool
+	credentialProcess    bool
+	planFile             string
+	mfaProcess           string
+	refreshBefore        time.Duration
+	jitter               time.Duration
+	serveECS             bool
+	ecsPort              int
+
+	tokenProvider TokenProvider
+
+	accountId string
+	roleName  string
+	roleArn   string
+
+	ssoStartUrl  string
+	ssoRegion    string
+	ssoAccountId string
+	ssoRoleName  string
+	ssoSession   string
+}
+
+func NewSwampConfig() *SwampConfig {
+	return &SwampConfig{}
+}
+
+func (c *SwampConfig) SetupFlags() {
+	flag.StringVar(&c.profile, "profile", "", "base AWS profile (holds the MFA device or SSO config)")
+	flag.StringVar(&c.intermediateProfile, "intermediate-profile", "swamp-intermediate", "profile to cache the intermediate session token in")
+	flag.StringVar(&c.targetProfile, "target-profile", "", "profile to write the assumed-role credentials to")
+	flag.StringVar(&c.region, "region", "us-east-1", "AWS region")
+	flag.StringVar(&c.tokenSerialNumber, "mfa-serial", "", "ARN of the MFA device to use for GetSessionToken")
+	flag.Int64Var(&c.intermediateDuration, "intermediate-duration", 3600, "duration in seconds of the intermediate session token")
+	flag.Int64Var(&c.targetDuration, "target-duration", 3600, "duration in seconds of the assumed-role credentials")
+	flag.BoolVar(&c.renew, "renew", false, "keep swamp running and renew credentials before they expire")
+	flag.BoolVar(&c.exportProfile, "export", false, "write an export-file with shell commands to select -target-profile")
+	flag.StringVar(&c.exportFile, "export-file", "", "path to write the -export shell snippet to")
+	flag.BoolVar(&c.useInstanceProfile, "use-instance-profile", false, "use the EC2/ECS instance profile instead of -profile")
+	flag.BoolVar(&c.credentialProcess, "credential-process", false, "print credentials as a credential_process JSON document instead of writing -target-profile")
+	flag.StringVar(&c.planFile, "plan", "", "YAML or JSON file listing multiple {target_profile, account_id, role_name, region, duration} targets to assume into from a single MFA prompt")
+	flag.StringVar(&c.mfaProcess, "mfa-process", "", "command supplying the mfa token code on stdout, prefix with ykman: to run `ykman oath accounts code <name>`; defaults to a stdin prompt")
+	flag.DurationVar(&c.refreshBefore, "refresh-before", 5*time.Minute, "in -renew mode, refresh credentials this long before they actually expire")
+	flag.DurationVar(&c.jitter, "jitter", 30*time.Second, "in -renew mode, add up to this much random jitter to the refresh delay")
+	flag.BoolVar(&c.serveECS, "serve-ecs", false, "serve assumed-role credentials from a local ECS container credentials endpoint instead of writing -target-profile")
+	flag.IntVar(&c.ecsPort, "ecs-port", 0, "port for -serve-ecs to listen on, 0 picks a free port")
+
+	flag.StringVar(&c.accountId, "account-id", "", "account id of the role to assume (used with -role-name)")
+	flag.StringVar(&c.roleName, "role-name", "", "name of the role to assume (used with -account-id)")
+	flag.StringVar(&c.roleArn, "role-arn", "", "full ARN of the role to assume, overrides -account-id/-role-name")
+
+	flag.StringVar(&c.ssoStartUrl, "sso-start-url", "", "AWS SSO start URL, makes -profile's base credentials come from Identity Center")
+	flag.StringVar(&c.ssoRegion, "sso-region", "", "region of the AWS SSO/Identity Center instance")
+	flag.StringVar(&c.ssoAccountId, "sso-account-id", "", "account id of the permission set to request from SSO")
+	flag.StringVar(&c.ssoRoleName, "sso-role-name", "", "permission set / role name to request from SSO")
+	flag.StringVar(&c.ssoSession, "sso-session", "", "named sso-session to key the cached SSO token under, defaults to -sso-start-url")
+}
+
+func (c *SwampConfig) Validate() {
+	if c.planFile == "" {
+		if c.targetProfile == "" && !c.credentialProcess && !c.serveECS {
+			die("Missing required flag", fmt.Errorf("-target-profile is required"))
+		}
+
+		if c.roleArn == "" && (c.accountId == "" || c.roleName == "") {
+			die("Missing required flag", fmt.Errorf("either -role-arn or both -account-id and -role-name are required"))
+		}
+	}
+
+	if c.IsSSO() {
+		if c.ssoRegion == "" || c.ssoAccountId == "" || c.ssoRoleName == "" {
+			die("Missing required flag", fmt.Errorf("-sso-start-url requires -sso-region, -sso-account-id and -sso-role-name"))
+		}
+	} else if c.profile == "" && !c.useInstanceProfile {
+		die("Missing required flag", fmt.Errorf("-profile is required unless -use-instance-profile or -sso-start-url is set"))
+	}
+}
+
+// IsSSO reports whether the base credentials should come from AWS SSO /
+// Identity Center rather than the GetSessionToken+MFA path.
+func (c *SwampConfig) IsSSO() bool {
+	return c.ssoStartUrl != ""
+}
+
+// ssoCacheKey is the key the cached SSO token is stored under, matching
+// the aws-cli convention of keying on -sso-session when one is set.
+func (c *SwampConfig) ssoCacheKey() string {
+	if c.ssoSession != "" {
+		return c.ssoSession
+	}
+	return c.ssoStartUrl
+}
+
+// TokenProvider returns the TokenProvider selected by -mfa-process,
+// building and caching it on first use.
+func (c *SwampConfig) TokenProvider() TokenProvider {
+	if c.tokenProvider == nil {
+		c.tokenProvider = NewTokenProvider(c.mfaProcess)
+	}
+	return c.tokenProvider
+}
+
+func (c *SwampConfig) GetRoleArn() *string {
+	if c.roleArn != "" {
+		return &c.roleArn
+	}
+
+	arn := fmt.Sprintf("arn:aws:iam::%s:role/%s", c.accountId, c.roleName)
+	return &arn
+}
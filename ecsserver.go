@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ecsCredentialCache holds the most recently assumed credentials the
+// ECS credentials endpoint serves; the renew loop updates it in place.
+type ecsCredentialCache struct {
+	mu      sync.Mutex
+	cred    *sts.Credentials
+	roleArn string
+}
+
+func (c *ecsCredentialCache) set(cred *sts.Credentials, roleArn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cred = cred
+	c.roleArn = roleArn
+}
+
+func (c *ecsCredentialCache) get() (*sts.Credentials, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cred, c.roleArn
+}
+
+// ecsCredentialsResponse is the shape the ECS container credentials
+// protocol expects from GET /creds.
+type ecsCredentialsResponse struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+	RoleArn         string
+}
+
+func newECSAuthToken() string {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		die("Error generating ecs auth token", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// startECSCredentialServer starts the local ECS container credentials
+// endpoint on 127.0.0.1:port (0 picks a free port), prints the
+// AWS_CONTAINER_CREDENTIALS_FULL_URI/AWS_CONTAINER_AUTHORIZATION_TOKEN
+// the caller should export, and returns the cache the renew loop should
+// keep updated.
+func startECSCredentialServer(port int) *ecsCredentialCache {
+	cache := &ecsCredentialCache{}
+	token := newECSAuthToken()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/creds", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		cred, roleArn := cache.get()
+		if cred == nil {
+			http.Error(w, "credentials not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ecsCredentialsResponse{
+			AccessKeyId:     *cred.AccessKeyId,
+			SecretAccessKey: *cred.SecretAccessKey,
+			Token:           *cred.SessionToken,
+			Expiration:      cred.Expiration.Format(time.RFC3339),
+			RoleArn:         roleArn,
+		})
+	})
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		die("Error starting ecs credential server", err)
+	}
+
+	go func() {
+		die("Error serving ecs credentials", http.Serve(listener, mux))
+	}()
+
+	fmt.Printf("export AWS_CONTAINER_CREDENTIALS_FULL_URI=http://%s/creds\n", listener.Addr())
+	fmt.Printf("export AWS_CONTAINER_AUTHORIZATION_TOKEN=%s\n", token)
+
+	return cache
+}
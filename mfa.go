@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// TokenProvider supplies the current MFA token code for a device, or
+// nil if serialNumber is empty (no MFA configured).
+type TokenProvider interface {
+	GetTokenCode(serialNumber string) *string
+}
+
+// stdinTokenProvider is the original behavior: prompt on the terminal.
+type stdinTokenProvider struct{}
+
+func (stdinTokenProvider) GetTokenCode(serialNumber string) *string {
+	if serialNumber == "" {
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Enter mfa token for %s: ", serialNumber)
+	tokenCode, err := reader.ReadString('\n')
+	if err != nil {
+		die("Error reading mfa token", err)
+		return nil
+	}
+
+	tokenCode = strings.Trim(tokenCode, " \r\n")
+	return &tokenCode
+}
+
+// execTokenProvider runs an arbitrary shell command and takes its
+// trimmed stdout as the token code.
+type execTokenProvider struct {
+	command string
+}
+
+func (p execTokenProvider) GetTokenCode(serialNumber string) *string {
+	if serialNumber == "" {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", p.command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		die(fmt.Sprintf("Error running -mfa-process command (%s)", strings.TrimSpace(stderr.String())), err)
+		return nil
+	}
+
+	tokenCode := strings.TrimSpace(string(out))
+	return &tokenCode
+}
+
+// NewTokenProvider builds the TokenProvider selected by -mfa-process.
+// An empty spec prompts on stdin; "ykman:<account>" shells out to
+// `ykman oath accounts code <account>`; anything else is run as-is.
+func NewTokenProvider(spec string) TokenProvider {
+	switch {
+	case spec == "":
+		return stdinTokenProvider{}
+	case strings.HasPrefix(spec, "ykman:"):
+		account := strings.TrimPrefix(spec, "ykman:")
+		return execTokenProvider{command: fmt.Sprintf("ykman oath accounts code %s", account)}
+	default:
+		return execTokenProvider{command: spec}
+	}
+}
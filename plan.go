@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlanTarget is one entry of a -plan file: an account/role to assume
+// into and the profile its credentials should be written to.
+type PlanTarget struct {
+	TargetProfile string `yaml:"target_profile" json:"target_profile"`
+	AccountId     string `yaml:"account_id" json:"account_id"`
+	RoleName      string `yaml:"role_name" json:"role_name"`
+	Region        string `yaml:"region" json:"region"`
+	Duration      int64  `yaml:"duration" json:"duration"`
+}
+
+func (t *PlanTarget) RoleArn() string {
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s", t.AccountId, t.RoleName)
+}
+
+// LoadPlan reads a list of PlanTargets from a YAML or JSON file,
+// selecting the format by file extension (.json, else YAML). Entries
+// that omit duration fall back to defaultDuration, since STS rejects
+// DurationSeconds below 900 outright.
+func LoadPlan(path string, defaultDuration int64) []PlanTarget {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		die("Error reading plan file", err)
+	}
+
+	var targets []PlanTarget
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &targets)
+	} else {
+		err = yaml.Unmarshal(data, &targets)
+	}
+	if err != nil {
+		die("Error parsing plan file", err)
+	}
+
+	for i := range targets {
+		if targets[i].TargetProfile == "" || targets[i].AccountId == "" || targets[i].RoleName == "" {
+			die("Error parsing plan file", fmt.Errorf("entry %d is missing target_profile, account_id or role_name", i))
+		}
+		if targets[i].Duration == 0 {
+			targets[i].Duration = defaultDuration
+		}
+	}
+
+	return targets
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writePlanFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("writing plan fixture: %v", err)
+	}
+	return path
+}
+
+// assertLoadPlanDies runs LoadPlan(path, 3600) in a subprocess, since it
+// calls die() -> os.Exit(1) on bad input rather than returning an error.
+func assertLoadPlanDies(t *testing.T, path string) {
+	t.Helper()
+
+	if os.Getenv("SWAMP_TEST_LOAD_PLAN_PATH") != "" {
+		LoadPlan(os.Getenv("SWAMP_TEST_LOAD_PLAN_PATH"), 3600)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$")
+	cmd.Env = append(os.Environ(), "SWAMP_TEST_LOAD_PLAN_PATH="+path)
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("LoadPlan(%q) did not die on invalid input", path)
+	}
+}
+
+func TestPlanTargetRoleArn(t *testing.T) {
+	target := PlanTarget{AccountId: "123456789012", RoleName: "deploy"}
+	want := "arn:aws:iam::123456789012:role/deploy"
+	if got := target.RoleArn(); got != want {
+		t.Errorf("RoleArn() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadPlanYAML(t *testing.T) {
+	path := writePlanFile(t, "plan.yaml", `
+- target_profile: prod-admin
+  account_id: "111111111111"
+  role_name: admin
+  region: us-west-2
+  duration: 1800
+- target_profile: staging-admin
+  account_id: "222222222222"
+  role_name: admin
+`)
+
+	targets := LoadPlan(path, 3600)
+	if len(targets) != 2 {
+		t.Fatalf("got %d targets, want 2", len(targets))
+	}
+
+	if targets[0].Duration != 1800 {
+		t.Errorf("targets[0].Duration = %d, want 1800", targets[0].Duration)
+	}
+
+	if targets[1].Duration != 3600 {
+		t.Errorf("targets[1].Duration defaulted to %d, want 3600", targets[1].Duration)
+	}
+}
+
+func TestLoadPlanJSON(t *testing.T) {
+	path := writePlanFile(t, "plan.json", `[
+		{"target_profile": "prod-admin", "account_id": "111111111111", "role_name": "admin"}
+	]`)
+
+	targets := LoadPlan(path, 900)
+	if len(targets) != 1 {
+		t.Fatalf("got %d targets, want 1", len(targets))
+	}
+
+	if targets[0].Duration != 900 {
+		t.Errorf("targets[0].Duration defaulted to %d, want 900", targets[0].Duration)
+	}
+}
+
+func TestLoadPlanMissingRequiredField(t *testing.T) {
+	path := writePlanFile(t, "plan.yaml", `
+- target_profile: prod-admin
+  account_id: "111111111111"
+`)
+	assertLoadPlanDies(t, path)
+}
+
+func TestLoadPlanMalformed(t *testing.T) {
+	path := writePlanFile(t, "plan.yaml", "not: [valid")
+	assertLoadPlanDies(t, path)
+}
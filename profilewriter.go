@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"gopkg.in/ini.v1"
+)
+
+// ProfileWriter writes assumed-role/session-token credentials into the
+// shared ~/.aws/credentials file so the AWS CLI and SDKs can pick a
+// profile up via -profile/AWS_PROFILE.
+type ProfileWriter struct {
+	path string
+}
+
+func NewProfileWriter() *ProfileWriter {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		die("Error locating home directory", err)
+	}
+
+	return &ProfileWriter{path: filepath.Join(home, ".aws", "credentials")}
+}
+
+func (pw *ProfileWriter) writeProfile(cred *sts.Credentials, profile *string, region *string) {
+	cfg, err := ini.LooseLoad(pw.path)
+	if err != nil {
+		die("Error loading credentials file", err)
+	}
+
+	section, err := cfg.NewSection(*profile)
+	if err != nil {
+		die("Error creating profile section", err)
+	}
+
+	section.Key("aws_access_key_id").SetValue(*cred.AccessKeyId)
+	section.Key("aws_secret_access_key").SetValue(*cred.SecretAccessKey)
+	section.Key("aws_session_token").SetValue(*cred.SessionToken)
+	if region != nil {
+		section.Key("region").SetValue(*region)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pw.path), 0700); err != nil {
+		die("Error creating .aws directory", err)
+	}
+
+	if err := cfg.SaveTo(pw.path); err != nil {
+		die("Error writing credentials file", err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sso"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ssoCacheToken mirrors the on-disk JSON cache format shared with the
+// AWS CLI's sso-oidc cache, so a token fetched by one is reusable by
+// the other.
+type ssoCacheToken struct {
+	ClientId     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	AccessToken  string `json:"accessToken"`
+	ExpiresAt    string `json:"expiresAt"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+func ssoCacheFilePath(key string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		die("Error locating home directory", err)
+	}
+
+	hash := sha1.Sum([]byte(key))
+	return filepath.Join(home, ".aws", "sso", "cache", fmt.Sprintf("%x.json", hash))
+}
+
+// loadCachedSSOToken returns the cached token for config's sso-session
+// (or start URL), or nil if there is none or it has expired.
+func loadCachedSSOToken(config *SwampConfig) *ssoCacheToken {
+	data, err := os.ReadFile(ssoCacheFilePath(config.ssoCacheKey()))
+	if err != nil {
+		return nil
+	}
+
+	var token ssoCacheToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil || time.Now().After(expiresAt) {
+		return nil
+	}
+
+	return &token
+}
+
+func writeCachedSSOToken(config *SwampConfig, token *ssoCacheToken) {
+	path := ssoCacheFilePath(config.ssoCacheKey())
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		die("Error creating sso cache directory", err)
+	}
+
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		die("Error encoding sso cache token", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		die("Error writing sso cache token", err)
+	}
+}
+
+// registerSSODeviceToken runs the sso-oidc device-code flow, printing
+// the verification URL for the user to approve, then caches and
+// returns the resulting token.
+func registerSSODeviceToken(config *SwampConfig) *ssoCacheToken {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Region: &config.ssoRegion},
+	}))
+	oidc := ssooidc.New(sess)
+
+	client, err := oidc.RegisterClient(&ssooidc.RegisterClientInput{
+		ClientName: aws.String("swamp"),
+		ClientType: aws.String("public"),
+	})
+	if err != nil {
+		die("Error registering sso-oidc client", err)
+	}
+
+	auth, err := oidc.StartDeviceAuthorization(&ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     client.ClientId,
+		ClientSecret: client.ClientSecret,
+		StartUrl:     &config.ssoStartUrl,
+	})
+	if err != nil {
+		die("Error starting sso device authorization", err)
+	}
+
+	fmt.Printf("Open %s and confirm code %s to continue\n", *auth.VerificationUriComplete, *auth.UserCode)
+
+	deadline := time.Now().Add(time.Duration(*auth.ExpiresIn) * time.Second)
+	interval := time.Duration(*auth.Interval) * time.Second
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		output, err := oidc.CreateToken(&ssooidc.CreateTokenInput{
+			ClientId:     client.ClientId,
+			ClientSecret: client.ClientSecret,
+			DeviceCode:   auth.DeviceCode,
+			GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok {
+				switch aerr.Code() {
+				case ssooidc.ErrCodeAuthorizationPendingException:
+					continue
+				case ssooidc.ErrCodeSlowDownException:
+					interval += 5 * time.Second
+					continue
+				}
+			}
+			die("Error creating sso-oidc token", err)
+		}
+
+		token := &ssoCacheToken{
+			ClientId:     *client.ClientId,
+			ClientSecret: *client.ClientSecret,
+			AccessToken:  *output.AccessToken,
+			ExpiresAt:    time.Now().Add(time.Duration(*output.ExpiresIn) * time.Second).Format(time.RFC3339),
+		}
+		if output.RefreshToken != nil {
+			token.RefreshToken = *output.RefreshToken
+		}
+
+		writeCachedSSOToken(config, token)
+		return token
+	}
+
+	die("Error waiting for sso device authorization", fmt.Errorf("authorization window expired"))
+	return nil
+}
+
+// getSSORoleCredentials exchanges a valid SSO access token for the
+// short-lived credentials of config's sso-account-id/sso-role-name
+// permission set.
+func getSSORoleCredentials(config *SwampConfig) *sts.Credentials {
+	token := loadCachedSSOToken(config)
+	if token == nil {
+		token = registerSSODeviceToken(config)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Region: &config.ssoRegion},
+	}))
+	svc := sso.New(sess)
+
+	output, err := svc.GetRoleCredentials(&sso.GetRoleCredentialsInput{
+		AccessToken: &token.AccessToken,
+		AccountId:   &config.ssoAccountId,
+		RoleName:    &config.ssoRoleName,
+	})
+	if err != nil {
+		die("Error fetching sso role credentials", err)
+	}
+
+	rc := output.RoleCredentials
+	return &sts.Credentials{
+		AccessKeyId:     rc.AccessKeyId,
+		SecretAccessKey: rc.SecretAccessKey,
+		SessionToken:    rc.SessionToken,
+		Expiration:      aws.Time(time.UnixMilli(*rc.Expiration)),
+	}
+}
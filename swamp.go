@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sts"
 )
@@ -27,22 +28,6 @@ func getCallerId(svc *sts.STS) *sts.GetCallerIdentityOutput {
 	return output
 }
 
-func getTokenCode(tokenSerialNumber string) *string {
-	if tokenSerialNumber == "" {
-		return nil
-	}
-
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("Enter mfa token for %s: ", tokenSerialNumber)
-	if tokenCode, err := reader.ReadString('\n'); err != nil {
-		die("Error reading mfa token", err)
-		return nil
-	} else {
-		tokenCode = strings.Trim(tokenCode, " \r\n")
-		return &tokenCode
-	}
-}
-
 func validateSessionToken(options session.Options) bool {
 	sess := session.Must(session.NewSessionWithOptions(options))
 	svc := sts.New(sess)
@@ -53,10 +38,21 @@ func validateSessionToken(options session.Options) bool {
 func getSessionToken(options session.Options, config *SwampConfig) *sts.Credentials {
 	sess := session.Must(session.NewSessionWithOptions(options))
 	svc := sts.New(sess)
-	output, err := svc.GetSessionToken(&sts.GetSessionTokenInput{
-		DurationSeconds: &config.intermediateDuration,
-		SerialNumber:    &config.tokenSerialNumber,
-		TokenCode:       getTokenCode(config.tokenSerialNumber),
+
+	tokenCode := config.TokenProvider().GetTokenCode(config.tokenSerialNumber)
+
+	var output *sts.GetSessionTokenOutput
+	err := withBackoff(func() error {
+		out, err := svc.GetSessionToken(&sts.GetSessionTokenInput{
+			DurationSeconds: &config.intermediateDuration,
+			SerialNumber:    &config.tokenSerialNumber,
+			TokenCode:       tokenCode,
+		})
+		if err != nil {
+			return err
+		}
+		output = out
+		return nil
 	})
 	if err != nil {
 		die("Error getting session token", err)
@@ -83,10 +79,18 @@ func ensureSessionTokenProfile(config *SwampConfig, pw *ProfileWriter) {
 }
 
 func assumeRole(svc *sts.STS, roleArn, roleSessionName *string, duration *int64) *sts.Credentials {
-	output, err := svc.AssumeRole(&sts.AssumeRoleInput{
-		RoleArn:         roleArn,
-		RoleSessionName: roleSessionName,
-		DurationSeconds: duration,
+	var output *sts.AssumeRoleOutput
+	err := withBackoff(func() error {
+		out, err := svc.AssumeRole(&sts.AssumeRoleInput{
+			RoleArn:         roleArn,
+			RoleSessionName: roleSessionName,
+			DurationSeconds: duration,
+		})
+		if err != nil {
+			return err
+		}
+		output = out
+		return nil
 	})
 	if err != nil {
 		die("Error assuming role", err)
@@ -95,16 +99,92 @@ func assumeRole(svc *sts.STS, roleArn, roleSessionName *string, duration *int64)
 	return output.Credentials
 }
 
-// assume-role into target account and write target profile into .aws/credentials
-func ensureTargetProfile(config *SwampConfig, pw *ProfileWriter, sess *session.Session) {
+func getTargetCredentials(config *SwampConfig, sess *session.Session) *sts.Credentials {
 	svc := sts.New(sess)
 
 	userId := getCallerId(svc).Arn
 	parts := strings.Split(*userId, "/")
 	roleSessionName := parts[len(parts) - 1]
 
-	cred := assumeRole(svc, config.GetRoleArn(), &roleSessionName, &config.targetDuration)
+	return assumeRole(svc, config.GetRoleArn(), &roleSessionName, &config.targetDuration)
+}
+
+// assume-role into target account and write target profile into .aws/credentials
+func ensureTargetProfile(config *SwampConfig, pw *ProfileWriter, sess *session.Session) *sts.Credentials {
+	cred := getTargetCredentials(config, sess)
 	pw.writeProfile(cred, &config.targetProfile, sess.Config.Region)
+	return cred
+}
+
+// assume-role into every target listed in a -plan file, reusing the
+// same intermediate session so the user is only prompted for MFA once.
+// Returns the soonest-expiring credentials, to drive the proactive
+// refresh sleep in -renew mode.
+func ensurePlanTargetProfiles(pw *ProfileWriter, sess *session.Session, targets []PlanTarget) *time.Time {
+	svc := sts.New(sess)
+
+	userId := getCallerId(svc).Arn
+	parts := strings.Split(*userId, "/")
+	roleSessionName := parts[len(parts) - 1]
+
+	var soonest *time.Time
+	for i := range targets {
+		target := &targets[i]
+		roleArn := target.RoleArn()
+		cred := assumeRole(svc, &roleArn, &roleSessionName, &target.Duration)
+
+		region := &target.Region
+		if target.Region == "" {
+			region = sess.Config.Region
+		}
+		pw.writeProfile(cred, &target.TargetProfile, region)
+
+		if soonest == nil || cred.Expiration.Before(*soonest) {
+			soonest = cred.Expiration
+		}
+	}
+
+	return soonest
+}
+
+// credentialProcessOutput is the JSON document AWS SDKs expect from a
+// credential_process helper.
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+func writeCredentialProcessOutput(cred *sts.Credentials) {
+	output := credentialProcessOutput{
+		Version:         1,
+		AccessKeyId:     *cred.AccessKeyId,
+		SecretAccessKey: *cred.SecretAccessKey,
+		SessionToken:    *cred.SessionToken,
+		Expiration:      cred.Expiration.Format(time.RFC3339),
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		die("Error encoding credential-process output", err)
+	}
+
+	fmt.Println(string(data))
+}
+
+// getSSOSession exchanges the cached (or freshly authorized) SSO access
+// token for role credentials and wraps them in a session, skipping the
+// GetSessionToken/MFA dance entirely.
+func getSSOSession(config *SwampConfig) *session.Session {
+	cred := getSSORoleCredentials(config)
+	return session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region:      &config.region,
+			Credentials: credentials.NewStaticCredentials(*cred.AccessKeyId, *cred.SecretAccessKey, *cred.SessionToken),
+		},
+	}))
 }
 
 func writeProfileToFile(config *SwampConfig) {
@@ -131,7 +211,13 @@ func main() {
 		baseProfile = &config.intermediateProfile
 	}
 
+	var plan []PlanTarget
+	if config.planFile != "" {
+		plan = LoadPlan(config.planFile, config.targetDuration)
+	}
+
 	pw := NewProfileWriter()
+	var ecsCache *ecsCredentialCache
 	for {
 		if config.tokenSerialNumber != "" {
 			// get intermediate session token with mfa, use that to assume role into target account
@@ -139,23 +225,44 @@ func main() {
 		}
 
 		var sess *session.Session
-		if config.useInstanceProfile {
+		switch {
+		case config.useInstanceProfile:
 			sess = session.Must(session.NewSession())
-		} else {
+		case config.IsSSO():
+			// sso grants access to the sso-account-id/sso-role-name permission
+			// set directly, so there's no intermediate profile to write
+			sess = getSSOSession(config)
+		default:
 			sess = session.Must(session.NewSessionWithOptions(session.Options{
 				Config:  aws.Config{Region: &config.region},
 				Profile: *baseProfile, }))
 		}
 
-		ensureTargetProfile(config, pw, sess)
+		var expiration *time.Time
+		if plan != nil {
+			expiration = ensurePlanTargetProfiles(pw, sess, plan)
+		} else if config.credentialProcess {
+			cred := getTargetCredentials(config, sess)
+			writeCredentialProcessOutput(cred)
+			break
+		} else if config.serveECS {
+			cred := getTargetCredentials(config, sess)
+			if ecsCache == nil {
+				ecsCache = startECSCredentialServer(config.ecsPort)
+			}
+			ecsCache.set(cred, *config.GetRoleArn())
+			expiration = cred.Expiration
+		} else {
+			expiration = ensureTargetProfile(config, pw, sess).Expiration
+		}
 
 		if config.exportProfile {
 			writeProfileToFile(config)
 		}
 
-		if !config.renew {
+		if !config.renew && !config.serveECS {
 			break
 		}
-		time.Sleep(time.Second * time.Duration(config.targetDuration / 2))
+		time.Sleep(refreshDelay(expiration, config.refreshBefore, config.jitter))
 	}
 }